@@ -17,6 +17,13 @@
 // * Added custom seek method, to seek using percentage.
 // * Added volume property to Decoder.
 // * Added getter/setters for `Decoder.volume`.
+// * Parsed Xing/Info/VBRI VBR headers for fast length/seek on VBR streams.
+// * Added float32 PCM output mode, selectable via NewDecoderWithOptions.
+// * Parsed ID3v1/ID3v2/APEv2 tags instead of discarding them, exposed via
+//   (*Decoder).Metadata().
+// * Added ReplayGain-aware playback via (*Decoder).SetReplayGainMode.
+// * Added NewDecoderFromURL, an HTTP-range-backed seekable source.
+// * Added Position and SeekDuration for time-based playback control.
 
 package mp3
 
@@ -24,10 +31,12 @@ import (
 	"errors"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/sukus21/go-mp3/internal/consts"
 	"github.com/sukus21/go-mp3/internal/frame"
 	"github.com/sukus21/go-mp3/internal/frameheader"
+	"github.com/sukus21/go-mp3/internal/vbr"
 )
 
 // A Decoder is a MP3-decoded stream.
@@ -44,6 +53,23 @@ type Decoder struct {
 	bytesPerFrame int64
 	mux           sync.Mutex
 	volume        float32
+
+	// VBR bookkeeping, populated from a Xing/Info or VBRI tag when
+	// present. When isVBR is false the tag-derived fields are unused
+	// (totalFrames and firstFrameOffset are still filled in by the scan)
+	// and every seek goes through the frameStarts-based CBR path below.
+	isVBR            bool
+	totalFrames      int64
+	samplesPerFrame  int64
+	firstFrameOffset int64
+	vbrTotalBytes    int64
+	vbrTOC           [100]byte
+	hasVBRTOC        bool
+
+	format SampleFormat
+
+	metadata *Metadata
+	rg       replayGain
 }
 
 // !!! NEW TO DERIVATIVE WORK !!!
@@ -65,7 +91,12 @@ func (d *Decoder) readFrame() error {
 		}
 		return err
 	}
-	d.buf = append(d.buf, d.frame.Decode(d.volume)...)
+	pcm := d.frame.Decode(d.volume * d.replayGainMultiplier())
+	clipS16LE(pcm, d.replayGainCeiling())
+	if d.format == SampleFormatF32LE {
+		pcm = s16ToF32LE(pcm)
+	}
+	d.buf = append(d.buf, pcm...)
 	return nil
 }
 
@@ -108,6 +139,13 @@ func (d *Decoder) Seek(offset int64, whence int) (int64, error) {
 	default:
 		return 0, errors.New("mp3: invalid whence")
 	}
+
+	// A VBR stream with only a TOC has no frameStarts table yet; byte
+	// offsets need sample-accurate seeking, so build it now.
+	if err := d.ensureFrameStarts(); err != nil {
+		return 0, err
+	}
+
 	d.pos = npos
 	d.buf = nil
 	d.frame = nil
@@ -158,6 +196,14 @@ func (d *Decoder) SeekPercent(where float64) (int64, error) {
 	d.mux.Lock()
 	defer d.mux.Unlock()
 
+	if d.isVBR && d.hasVBRTOC {
+		return d.seekPercentTOC(where)
+	}
+
+	if err := d.ensureFrameStarts(); err != nil {
+		return 0, err
+	}
+
 	//How far is it acceptable to go back?
 	offset := 4
 	frameIndex := int64(float64(len(d.frameStarts))*where) - int64(offset)
@@ -187,6 +233,31 @@ func (d *Decoder) SeekPercent(where float64) (int64, error) {
 	return d.pos, nil
 }
 
+// seekPercentTOC seeks using the Xing/Info/VBRI TOC instead of the
+// frameStarts table, so it works without ever scanning the stream.
+func (d *Decoder) seekPercentTOC(where float64) (int64, error) {
+	// d.vbrTOC has 100 entries (one per percentage point), each holding a
+	// fraction of vbrTotalBytes on a 0-255 scale. Don't conflate the two
+	// scales: the index into the table is 0-99, not 0-255.
+	entry := int(where * 100)
+	if entry > 99 {
+		entry = 99
+	}
+
+	byteOffset := d.firstFrameOffset + int64(d.vbrTOC[entry])*d.vbrTotalBytes/256
+	d.frame = nil
+	d.buf = nil
+	if _, err := d.source.Seek(byteOffset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if err := d.readFrame(); err != nil {
+		return 0, err
+	}
+
+	d.pos = int64(float64(d.length) * where)
+	return d.pos, nil
+}
+
 // SampleRate returns the sample rate like 44100.
 //
 // Note that the sample rate is retrieved from the first frame.
@@ -215,7 +286,78 @@ func (d *Decoder) ensureFrameStartsAndLength() error {
 	if err := d.source.skipTags(); err != nil {
 		return err
 	}
+	d.firstFrameOffset = d.source.pos
+
+	found, err := d.tryParseVBRHeader()
+	if err != nil {
+		return err
+	}
+	if found {
+		if _, err := d.source.Seek(pos, io.SeekStart); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	// tryParseVBRHeader consumed the first frame; rewind to it so the
+	// scan records every frame.
+	if _, err := d.source.Seek(d.firstFrameOffset, io.SeekStart); err != nil {
+		return err
+	}
+	return d.scanFrames(pos)
+}
+
+// tryParseVBRHeader inspects the first frame of the stream for a Xing/Info
+// or VBRI tag. When one is present, length and the seek TOC are taken
+// straight from the tag instead of scanning every frame in the file, and
+// frameStarts is left empty (see SeekPercent and ensureFrameStarts).
+func (d *Decoder) tryParseVBRHeader() (bool, error) {
+	h, _, err := frameheader.Read(d.source, d.source.pos)
+	if err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		if _, ok := err.(*consts.UnexpectedEOF); ok {
+			return false, nil
+		}
+		return false, err
+	}
+
+	framesize, err := h.FrameSize()
+	if err != nil {
+		return false, err
+	}
+	body := make([]byte, framesize-4)
+	if _, err := d.source.ReadFull(body); err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+
+	tag := vbr.Parse(body)
+	if tag == nil || tag.Frames == 0 {
+		return false, nil
+	}
+
+	d.isVBR = true
+	d.totalFrames = int64(tag.Frames)
+	d.vbrTotalBytes = int64(tag.Bytes)
+	d.hasVBRTOC = tag.HasTOC
+	d.vbrTOC = tag.TOC
+	d.bytesPerFrame = int64(h.BytesPerFrame()) * d.bytesPerSample() / 4
+	d.samplesPerFrame = samplesPerFrameFor(d.sampleRate)
+	d.length = d.totalFrames * d.samplesPerFrame * d.bytesPerSample()
+	return true, nil
+}
+
+// scanFrames walks every frame header in the stream, recording its start
+// offset and accumulating the decoded length. It is the CBR fallback, and
+// is also used lazily to build a sample-accurate frameStarts table for a
+// VBR stream that only exposed a TOC.
+func (d *Decoder) scanFrames(restorePos int64) error {
 	l := int64(0)
+	d.frameStarts = d.frameStarts[:0]
 	for {
 		h, pos, err := frameheader.Read(d.source, d.source.pos)
 		if err != nil {
@@ -229,7 +371,7 @@ func (d *Decoder) ensureFrameStartsAndLength() error {
 			return err
 		}
 		d.frameStarts = append(d.frameStarts, pos)
-		d.bytesPerFrame = int64(h.BytesPerFrame())
+		d.bytesPerFrame = int64(h.BytesPerFrame()) * d.bytesPerSample() / 4
 		l += d.bytesPerFrame
 
 		framesize, err := h.FrameSize()
@@ -245,13 +387,100 @@ func (d *Decoder) ensureFrameStartsAndLength() error {
 		}
 	}
 	d.length = l
+	d.totalFrames = int64(len(d.frameStarts))
 
-	if _, err := d.source.Seek(pos, io.SeekStart); err != nil {
+	if _, err := d.source.Seek(restorePos, io.SeekStart); err != nil {
 		return err
 	}
 	return nil
 }
 
+// ensureFrameStarts lazily builds the sample-accurate frameStarts table,
+// scanning the whole stream if it was skipped earlier because a VBR TOC
+// was found. Byte-offset Seek needs this table; percentage seeks on a VBR
+// stream do not, and so avoid the O(N) preload.
+func (d *Decoder) ensureFrameStarts() error {
+	if len(d.frameStarts) > 0 {
+		return nil
+	}
+	if _, ok := d.source.reader.(io.Seeker); !ok {
+		return nil
+	}
+
+	pos, err := d.source.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if err := d.source.rewind(); err != nil {
+		return err
+	}
+	if err := d.source.skipTags(); err != nil {
+		return err
+	}
+	return d.scanFrames(pos)
+}
+
+// samplesPerFrameFor returns the number of PCM samples a single MPEG Layer
+// III frame decodes to: 1152 for MPEG1, 576 for MPEG2/2.5. The sample rate
+// is enough to tell the two groups apart, since MPEG2/2.5 sample rates are
+// always below the lowest MPEG1 rate.
+func samplesPerFrameFor(sampleRate int) int64 {
+	if sampleRate < 32000 {
+		return 576
+	}
+	return 1152
+}
+
+// !!! NEW TO DERIVATIVE WORK !!!
+// IsVBR reports whether the stream carried a Xing/Info or VBRI VBR header.
+func (d *Decoder) IsVBR() bool {
+	return d.isVBR
+}
+
+// !!! NEW TO DERIVATIVE WORK !!!
+// TotalFrames returns the number of MP3 frames in the stream, or 0 if this
+// is not yet known (e.g. the source is not an io.Seeker).
+func (d *Decoder) TotalFrames() int64 {
+	return d.totalFrames
+}
+
+// !!! NEW TO DERIVATIVE WORK !!!
+// Duration returns the total playback duration of the stream, or 0 if
+// Length is not available.
+func (d *Decoder) Duration() time.Duration {
+	if d.length <= 0 || d.sampleRate <= 0 {
+		return 0
+	}
+	seconds := float64(d.length) / float64(d.bytesPerSample()) / float64(d.sampleRate)
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// !!! NEW TO DERIVATIVE WORK !!!
+// Position returns how far into the stream playback currently is.
+func (d *Decoder) Position() time.Duration {
+	if d.sampleRate <= 0 {
+		return 0
+	}
+	seconds := float64(d.pos) / float64(d.bytesPerSample()) / float64(d.sampleRate)
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// !!! NEW TO DERIVATIVE WORK !!!
+// SeekDuration seeks to the given playback position, translating it to a
+// PCM byte offset and dispatching to the existing Seek machinery. It
+// returns the position actually landed on, which may differ slightly from
+// pos since the offset is truncated to a whole sample frame.
+func (d *Decoder) SeekDuration(pos time.Duration) (time.Duration, error) {
+	bytesPerSample := d.bytesPerSample()
+	offset := int64(pos.Seconds()*float64(d.sampleRate)) * bytesPerSample
+	offset -= offset % bytesPerSample
+
+	if _, err := d.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return d.Position(), nil
+}
+
 const invalidLength = -1
 
 // Length returns the total size in bytes.
@@ -289,12 +518,25 @@ func (d *Decoder) SetVolume(volume float32) {
 // even if the source is single channel MP3.
 // Thus, a sample always consists of 4 bytes.
 func NewDecoder(r io.Reader) (*Decoder, error) {
+	return NewDecoderWithOptions(r)
+}
+
+// !!! NEW TO DERIVATIVE WORK !!!
+// NewDecoderWithOptions is like NewDecoder, but accepts DecoderOptions such
+// as WithSampleFormat to change the PCM layout Read produces.
+func NewDecoderWithOptions(r io.Reader, opts ...DecoderOption) (*Decoder, error) {
+	cfg := decoderConfig{format: SampleFormatS16LE}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	s := &source{
 		reader: r,
 	}
 	d := &Decoder{
 		source: s,
 		length: invalidLength,
+		format: cfg.format,
 	}
 
 	if err := s.skipTags(); err != nil {
@@ -314,6 +556,11 @@ func NewDecoder(r io.Reader) (*Decoder, error) {
 		return nil, err
 	}
 
+	if err := d.parseMetadata(); err != nil {
+		return nil, err
+	}
+	d.parseReplayGain()
+
 	d.volume = 1
 	return d, nil
 }
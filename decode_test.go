@@ -0,0 +1,65 @@
+package mp3
+
+import (
+	"bytes"
+	"testing"
+)
+
+// newTOCDecoder builds a minimal Decoder wired up for VBR TOC-based
+// seeking, without going through NewDecoder, so seekPercentTOC's
+// arithmetic can be exercised directly. The data is a run of silent
+// MPEG-1 Layer III frames (128 kbps, 44.1 kHz, stereo, no CRC: 417 bytes
+// each), so the frame read after each seek finds a valid frame.
+func newTOCDecoder(t *testing.T) *Decoder {
+	t.Helper()
+
+	const frameSize = 417
+	data := make([]byte, 1<<20)
+	for off := 0; off+frameSize <= len(data); off += frameSize {
+		copy(data[off:], []byte{0xFF, 0xFB, 0x90, 0x00})
+	}
+	d := &Decoder{
+		source:           &source{reader: bytes.NewReader(data)},
+		sampleRate:       44100,
+		length:           1_000_000,
+		bytesPerFrame:    4,
+		isVBR:            true,
+		hasVBRTOC:        true,
+		vbrTotalBytes:    int64(len(data)),
+		firstFrameOffset: 0,
+	}
+	for i := range d.vbrTOC {
+		d.vbrTOC[i] = byte(i * 256 / 100)
+	}
+	return d
+}
+
+// TestSeekPercentTOCPastFortyPercent guards against regressing the
+// out-of-range panic in seekPercentTOC: indexing vbrTOC (a [100]byte) with
+// a 0-255-scaled value instead of a 0-99-scaled one panicked for any
+// where above ~0.39.
+func TestSeekPercentTOCPastFortyPercent(t *testing.T) {
+	d := newTOCDecoder(t)
+
+	for _, where := range []float64{0, 0.1, 0.39, 0.4, 0.5, 0.75, 0.99, 1.0} {
+		if _, err := d.SeekPercent(where); err != nil {
+			t.Fatalf("SeekPercent(%v): %v", where, err)
+		}
+	}
+}
+
+func TestSeekPercentTOCPositionIsMonotonic(t *testing.T) {
+	d := newTOCDecoder(t)
+
+	prev := int64(-1)
+	for _, where := range []float64{0, 0.25, 0.5, 0.75, 1.0} {
+		pos, err := d.SeekPercent(where)
+		if err != nil {
+			t.Fatalf("SeekPercent(%v): %v", where, err)
+		}
+		if pos < prev {
+			t.Errorf("SeekPercent(%v) = %d, expected position to not decrease (prev %d)", where, pos, prev)
+		}
+		prev = pos
+	}
+}
@@ -0,0 +1,52 @@
+package mp3
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestS16ToF32LE(t *testing.T) {
+	tests := []struct {
+		name string
+		s16  int16
+		want float32
+	}{
+		{"silence", 0, 0},
+		{"full scale positive", 32767, 32767.0 / 32768},
+		{"full scale negative", -32768, -1},
+		{"half scale", 16384, 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pcm := make([]byte, 4)
+			binary.LittleEndian.PutUint16(pcm[0:2], uint16(tt.s16))
+			binary.LittleEndian.PutUint16(pcm[2:4], uint16(tt.s16))
+
+			out := s16ToF32LE(pcm)
+			if len(out) != 8 {
+				t.Fatalf("len(out) = %d, want 8", len(out))
+			}
+
+			left := math.Float32frombits(binary.LittleEndian.Uint32(out[0:4]))
+			right := math.Float32frombits(binary.LittleEndian.Uint32(out[4:8]))
+
+			const epsilon = 1e-6
+			if diff := float64(left - tt.want); diff > epsilon || diff < -epsilon {
+				t.Errorf("left = %v, want %v", left, tt.want)
+			}
+			if diff := float64(right - tt.want); diff > epsilon || diff < -epsilon {
+				t.Errorf("right = %v, want %v", right, tt.want)
+			}
+		})
+	}
+}
+
+func TestS16ToF32LELength(t *testing.T) {
+	pcm := make([]byte, 4*10) // 10 stereo sample frames, S16LE
+	out := s16ToF32LE(pcm)
+	if len(out) != 8*10 {
+		t.Errorf("len(out) = %d, want %d", len(out), 8*10)
+	}
+}
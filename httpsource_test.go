@@ -0,0 +1,203 @@
+package mp3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// rangeServer serves data out of a fixed in-memory buffer, honoring
+// Range requests, and records how many GET (range) requests it handled.
+type rangeServer struct {
+	data []byte
+
+	mu       sync.Mutex
+	requests int
+}
+
+func (s *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", fmt.Sprint(len(s.data)))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests++
+	s.mu.Unlock()
+
+	rng := r.Header.Get("Range")
+	if rng == "" {
+		w.Header().Set("Content-Length", fmt.Sprint(len(s.data)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(s.data)
+		return
+	}
+
+	var start, end int
+	if _, err := fmt.Sscanf(rng, "bytes=%d-%d", &start, &end); err != nil {
+		http.Error(w, "bad range", http.StatusBadRequest)
+		return
+	}
+	if end >= len(s.data) {
+		end = len(s.data) - 1
+	}
+
+	body := s.data[start : end+1]
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(s.data)))
+	w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write(body)
+}
+
+func (s *rangeServer) requestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func newTestSource(t *testing.T, srv *httptest.Server, chunkSize int64, dataLen int) *httpSource {
+	t.Helper()
+	s, err := probeHTTPSource(context.Background(), srv.URL, httpSourceConfig{
+		chunkSize: chunkSize,
+		client:    srv.Client(),
+	})
+	if err != nil {
+		t.Fatalf("probeHTTPSource: %v", err)
+	}
+	if s == nil {
+		t.Fatal("probeHTTPSource returned nil; expected range support to be detected")
+	}
+	if int(s.size) != dataLen {
+		t.Fatalf("size = %d, want %d", s.size, dataLen)
+	}
+	return s
+}
+
+func TestHTTPSourceReadAtCoalescesIntoChunks(t *testing.T) {
+	data := make([]byte, 10*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	srv := &rangeServer{data: data}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	src := newTestSource(t, ts, 1024, len(data))
+
+	got := make([]byte, 100)
+	if _, err := src.readAt(got, 500); err != nil {
+		t.Fatalf("readAt: %v", err)
+	}
+	if !bytes.Equal(got, data[500:600]) {
+		t.Error("readAt returned the wrong bytes")
+	}
+
+	// A second read inside the same chunk must not issue another
+	// request.
+	if _, err := src.readAt(got, 520); err != nil {
+		t.Fatalf("readAt: %v", err)
+	}
+	if n := srv.requestCount(); n != 1 {
+		t.Errorf("requestCount = %d, want 1 (second read should hit the cache)", n)
+	}
+}
+
+func TestHTTPSourceReadAtSpansMultipleChunks(t *testing.T) {
+	data := make([]byte, 10*1024)
+	rand.New(rand.NewSource(2)).Read(data)
+
+	srv := &rangeServer{data: data}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	src := newTestSource(t, ts, 1024, len(data))
+
+	got := make([]byte, 2048)
+	n, err := src.readAt(got, 512)
+	if err != nil {
+		t.Fatalf("readAt: %v", err)
+	}
+	if n != len(got) {
+		t.Fatalf("n = %d, want %d", n, len(got))
+	}
+	if !bytes.Equal(got, data[512:512+2048]) {
+		t.Error("readAt returned the wrong bytes across a chunk boundary")
+	}
+	if want := 3; srv.requestCount() != want { // chunks 0,1,2 of size 1024
+		t.Errorf("requestCount = %d, want %d", srv.requestCount(), want)
+	}
+}
+
+func TestHTTPSourceSeekAndRead(t *testing.T) {
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	srv := &rangeServer{data: data}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	src := newTestSource(t, ts, 8, len(data))
+
+	if _, err := src.Seek(4, 0); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	buf := make([]byte, 5)
+	n, err := src.Read(buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if string(buf[:n]) != "quick" {
+		t.Errorf("Read after Seek = %q, want %q", buf[:n], "quick")
+	}
+}
+
+func TestHTTPSourceReaderAtCacheAvoidsFetch(t *testing.T) {
+	data := []byte("cached payload data for the range test")
+	srv := &rangeServer{data: data}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	src, err := probeHTTPSource(context.Background(), ts.URL, httpSourceConfig{
+		chunkSize: int64(len(data)),
+		client:    ts.Client(),
+		cache:     bytes.NewReader(data),
+	})
+	if err != nil {
+		t.Fatalf("probeHTTPSource: %v", err)
+	}
+
+	buf := make([]byte, len(data))
+	if _, err := src.readAt(buf, 0); err != nil {
+		t.Fatalf("readAt: %v", err)
+	}
+	if !bytes.Equal(buf, data) {
+		t.Error("readAt returned the wrong bytes")
+	}
+	if n := srv.requestCount(); n != 0 {
+		t.Errorf("requestCount = %d, want 0 (should be served from the ReaderAt cache)", n)
+	}
+}
+
+func TestProbeHTTPSourceFallsBackWithoutRanges(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// No Accept-Ranges header: server doesn't support ranges.
+		w.Write([]byte("streamed body"))
+	}))
+	defer srv.Close()
+
+	src, err := probeHTTPSource(context.Background(), srv.URL, httpSourceConfig{
+		chunkSize: defaultChunkSize,
+		client:    srv.Client(),
+	})
+	if err != nil {
+		t.Fatalf("probeHTTPSource: %v", err)
+	}
+	if src != nil {
+		t.Error("expected probeHTTPSource to return nil when the server doesn't advertise range support")
+	}
+}
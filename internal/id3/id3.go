@@ -0,0 +1,465 @@
+// Package id3 parses the ID3v1, ID3v2 and APEv2 tags that MP3 files embed
+// at the start and/or end of the stream, so the decoder can expose track
+// metadata instead of just discarding the tag bytes.
+package id3
+
+import (
+	"encoding/binary"
+	"unicode/utf16"
+)
+
+// Metadata holds the tag fields common to ID3v1, ID3v2 and APEv2, plus a
+// generic bag (Frames) for anything a tag carried beyond the fields above,
+// keyed by ID3v2 frame ID (e.g. "TXXX:REPLAYGAIN_TRACK_GAIN") or APE item
+// name. Values are kept in the order they were encountered.
+type Metadata struct {
+	Title       string
+	Artist      string
+	Album       string
+	TrackNumber string
+	Year        string
+	Genre       string
+	Comment     string
+
+	// Picture is the raw image data from an ID3v2 APIC frame, if any.
+	Picture []byte
+
+	Frames map[string][]string
+}
+
+func newMetadata() *Metadata {
+	return &Metadata{Frames: make(map[string][]string)}
+}
+
+func (m *Metadata) addFrame(key, value string) {
+	m.Frames[key] = append(m.Frames[key], value)
+}
+
+// mergeFields overwrites m's well-known fields with other's, wherever
+// other has a non-empty value. Called in last-to-first order by Merge, so
+// the final state reflects the first (highest-priority) argument that set
+// each field.
+func (m *Metadata) mergeFields(other *Metadata) {
+	if other.Title != "" {
+		m.Title = other.Title
+	}
+	if other.Artist != "" {
+		m.Artist = other.Artist
+	}
+	if other.Album != "" {
+		m.Album = other.Album
+	}
+	if other.TrackNumber != "" {
+		m.TrackNumber = other.TrackNumber
+	}
+	if other.Year != "" {
+		m.Year = other.Year
+	}
+	if other.Genre != "" {
+		m.Genre = other.Genre
+	}
+	if other.Comment != "" {
+		m.Comment = other.Comment
+	}
+	if len(other.Picture) > 0 {
+		m.Picture = other.Picture
+	}
+}
+
+// mergeFrames appends other's Frames onto m's. Unlike mergeFields, this
+// must be called in first-to-last order by Merge, so that for any given
+// key, index 0 holds the highest-priority argument's value (matching the
+// priority documented on Merge), not the lowest-priority one.
+func (m *Metadata) mergeFrames(other *Metadata) {
+	for k, vs := range other.Frames {
+		m.Frames[k] = append(m.Frames[k], vs...)
+	}
+}
+
+// Merge combines tags from multiple sources (ID3v2, APEv2, ID3v1) into one
+// Metadata, with earlier arguments taking priority over later ones: for
+// the well-known fields a later argument only fills in a field that no
+// earlier one set, and for Frames index 0 is always the earliest argument that set
+// that key, since ID3v2 is the richest and most likely to be accurate.
+// nil entries are ignored.
+func Merge(tags ...*Metadata) *Metadata {
+	m := newMetadata()
+	for i := len(tags) - 1; i >= 0; i-- {
+		if tags[i] != nil {
+			m.mergeFields(tags[i])
+		}
+	}
+	for _, t := range tags {
+		if t != nil {
+			m.mergeFrames(t)
+		}
+	}
+	return m
+}
+
+// synchsafe decodes a 4-byte ID3v2 synchsafe integer, where only the low 7
+// bits of each byte are significant.
+func synchsafe(b []byte) uint32 {
+	return uint32(b[0])<<21 | uint32(b[1])<<14 | uint32(b[2])<<7 | uint32(b[3])
+}
+
+// ParseID3v2 reads an ID3v2 tag from the start of buf. It returns nil if
+// buf does not begin with an "ID3" header. size is the total number of
+// bytes the tag occupies including its header, so the caller can skip past
+// it to reach the first MP3 frame.
+func ParseID3v2(buf []byte) (tag *Metadata, size int64, ok bool) {
+	if len(buf) < 10 || string(buf[0:3]) != "ID3" {
+		return nil, 0, false
+	}
+
+	version := buf[3]
+	flags := buf[5]
+	bodySize := synchsafe(buf[6:10])
+	total := int64(10) + int64(bodySize)
+	if int64(len(buf)) < total {
+		return nil, 0, false
+	}
+	body := buf[10:total]
+
+	if flags&0x40 != 0 && len(body) >= 4 {
+		extSize := synchsafe(body[0:4])
+		if int64(extSize) <= int64(len(body)) {
+			body = body[extSize:]
+		}
+	}
+
+	m := newMetadata()
+
+	idLen, sizeLen, flagLen := 4, 4, 2
+	if version < 3 {
+		idLen, sizeLen, flagLen = 3, 3, 0
+	}
+	headerLen := idLen + sizeLen + flagLen
+
+	for len(body) >= headerLen {
+		id := string(body[:idLen])
+		if id[0] == 0 {
+			break
+		}
+
+		var frameSize uint32
+		switch {
+		case version < 3:
+			frameSize = uint32(body[idLen])<<16 | uint32(body[idLen+1])<<8 | uint32(body[idLen+2])
+		case version == 3:
+			frameSize = binary.BigEndian.Uint32(body[idLen : idLen+4])
+		default: // version >= 4 uses synchsafe frame sizes
+			frameSize = synchsafe(body[idLen : idLen+4])
+		}
+
+		if int64(headerLen)+int64(frameSize) > int64(len(body)) {
+			break
+		}
+		payload := body[headerLen : uint32(headerLen)+frameSize]
+		applyID3v2Frame(m, id, payload)
+		body = body[uint32(headerLen)+frameSize:]
+	}
+
+	return m, total, true
+}
+
+func applyID3v2Frame(m *Metadata, id string, payload []byte) {
+	if len(payload) == 0 {
+		return
+	}
+
+	switch id {
+	case "TIT2", "TT2":
+		m.Title = decodeText(payload)
+	case "TPE1", "TP1":
+		m.Artist = decodeText(payload)
+	case "TALB", "TAL":
+		m.Album = decodeText(payload)
+	case "TRCK", "TRK":
+		m.TrackNumber = decodeText(payload)
+	case "TYER", "TYE", "TDRC":
+		m.Year = decodeText(payload)
+	case "TCON", "TCO":
+		m.Genre = decodeText(payload)
+	case "COMM", "COM":
+		m.Comment = decodeCOMM(payload)
+	case "APIC", "PIC":
+		m.Picture = decodeAPIC(payload)
+	case "TXXX", "TXX":
+		desc, value := decodeTXXX(payload)
+		m.addFrame("TXXX:"+desc, value)
+	default:
+		m.addFrame(id, decodeText(payload))
+	}
+}
+
+// decodeText decodes an ID3v2 text frame payload: an encoding byte
+// followed by the text itself.
+func decodeText(payload []byte) string {
+	if len(payload) < 1 {
+		return ""
+	}
+	return decodeEncoded(payload[0], payload[1:])
+}
+
+// decodeCOMM decodes a COMM frame: encoding, 3-byte language code, a short
+// description, then the actual comment text, the latter two both encoded
+// and null-terminated/null-delimited.
+func decodeCOMM(payload []byte) string {
+	if len(payload) < 4 {
+		return ""
+	}
+	enc := payload[0]
+	rest := payload[4:]
+	_, text := splitEncodedCString(enc, rest)
+	return decodeEncoded(enc, text)
+}
+
+// decodeTXXX decodes a user-defined text frame: encoding, a description,
+// then the value, both encoded and null-delimited.
+func decodeTXXX(payload []byte) (desc, value string) {
+	if len(payload) < 1 {
+		return "", ""
+	}
+	enc := payload[0]
+	descBytes, valueBytes := splitEncodedCString(enc, payload[1:])
+	return decodeEncoded(enc, descBytes), decodeEncoded(enc, valueBytes)
+}
+
+// decodeAPIC decodes an APIC frame: encoding, a null-terminated MIME type
+// (always Latin-1), a picture-type byte, an encoded description, then the
+// raw picture bytes.
+func decodeAPIC(payload []byte) []byte {
+	if len(payload) < 1 {
+		return nil
+	}
+	enc := payload[0]
+	rest := payload[1:]
+
+	mimeEnd := indexNull(rest, 0)
+	if mimeEnd < 0 {
+		return nil
+	}
+	rest = rest[mimeEnd+1:]
+	if len(rest) < 1 {
+		return nil
+	}
+	rest = rest[1:] // picture type byte
+
+	_, picture := splitEncodedCString(enc, rest)
+	return picture
+}
+
+// splitEncodedCString splits off the first null-delimited (or
+// null-pair-delimited, for UTF-16) string from data, returning the raw
+// bytes before the delimiter and the raw bytes after it.
+func splitEncodedCString(enc byte, data []byte) (head, tail []byte) {
+	step := 1
+	if enc == 1 || enc == 2 {
+		step = 2
+	}
+	i := indexNull(data, step)
+	if i < 0 {
+		return data, nil
+	}
+	return data[:i], data[i+step:]
+}
+
+func indexNull(data []byte, step int) int {
+	if step == 2 {
+		for i := 0; i+1 < len(data); i += 2 {
+			if data[i] == 0 && data[i+1] == 0 {
+				return i
+			}
+		}
+		return -1
+	}
+	for i, b := range data {
+		if b == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// decodeEncoded decodes raw text bytes according to an ID3v2 encoding
+// byte: 0 = ISO-8859-1, 1 = UTF-16 with BOM, 2 = UTF-16BE, 3 = UTF-8.
+func decodeEncoded(enc byte, data []byte) string {
+	switch enc {
+	case 1:
+		return decodeUTF16(data, true)
+	case 2:
+		return decodeUTF16(data, false)
+	case 3:
+		return string(trimTrailingNulls(data))
+	default:
+		return decodeLatin1(trimTrailingNulls(data))
+	}
+}
+
+func decodeLatin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+func decodeUTF16(data []byte, hasBOM bool) string {
+	bigEndian := true
+	if hasBOM && len(data) >= 2 {
+		if data[0] == 0xFF && data[1] == 0xFE {
+			bigEndian = false
+			data = data[2:]
+		} else if data[0] == 0xFE && data[1] == 0xFF {
+			bigEndian = true
+			data = data[2:]
+		}
+	}
+
+	n := len(data) / 2
+	units := make([]uint16, 0, n)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, binary.BigEndian.Uint16(data[i:i+2]))
+		} else {
+			units = append(units, binary.LittleEndian.Uint16(data[i:i+2]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+func trimTrailingNulls(data []byte) []byte {
+	for len(data) > 0 && data[len(data)-1] == 0 {
+		data = data[:len(data)-1]
+	}
+	return data
+}
+
+// ParseID3v1 reads the trailing 128-byte ID3v1 tag from tail, which must be
+// the last 128 bytes of the file. It returns nil if tail does not start
+// with "TAG".
+func ParseID3v1(tail []byte) (*Metadata, bool) {
+	if len(tail) != 128 || string(tail[0:3]) != "TAG" {
+		return nil, false
+	}
+
+	m := newMetadata()
+	m.Title = decodeLatin1(trimTrailingNulls(tail[3:33]))
+	m.Artist = decodeLatin1(trimTrailingNulls(tail[33:63]))
+	m.Album = decodeLatin1(trimTrailingNulls(tail[63:93]))
+	m.Year = decodeLatin1(trimTrailingNulls(tail[93:97]))
+
+	comment := tail[97:127]
+	// ID3v1.1 stores a track number in the comment's last two bytes when
+	// the second-to-last byte is zero.
+	if comment[28] == 0 && comment[29] != 0 {
+		m.Comment = decodeLatin1(trimTrailingNulls(comment[:28]))
+		m.TrackNumber = itoa(int(comment[29]))
+	} else {
+		m.Comment = decodeLatin1(trimTrailingNulls(comment))
+	}
+
+	if genre := int(tail[127]); genre < len(id3v1Genres) {
+		m.Genre = id3v1Genres[genre]
+	}
+	return m, true
+}
+
+func itoa(n int) string {
+	if n == 0 {
+		return "0"
+	}
+	var buf [20]byte
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = byte('0' + n%10)
+		n /= 10
+	}
+	return string(buf[i:])
+}
+
+// id3v1Genres is the standard ID3v1 genre table, indexed by the tag's
+// trailing genre byte.
+var id3v1Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge",
+	"Hip-Hop", "Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B",
+	"Rap", "Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska",
+	"Death Metal", "Pranks", "Soundtrack", "Euro-Techno", "Ambient",
+	"Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance", "Classical",
+	"Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel",
+	"Noise", "AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative",
+	"Instrumental Pop", "Instrumental Rock", "Ethnic", "Gothic", "Darkwave",
+	"Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap",
+	"Pop/Funk", "Jungle", "Native American", "Cabaret", "New Wave",
+	"Psychedelic", "Rave", "Showtunes", "Trailer", "Lo-Fi", "Tribal",
+	"Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll",
+	"Hard Rock",
+}
+
+// ParseAPEv2 reads a trailing APEv2 tag from tail, which must end at the
+// tag's footer (i.e. at the end of the file, or just before a following
+// ID3v1 tag). It returns nil if tail does not end with an "APETAGEX"
+// footer.
+func ParseAPEv2(tail []byte) (*Metadata, bool) {
+	const footerLen = 32
+	if len(tail) < footerLen {
+		return nil, false
+	}
+	footer := tail[len(tail)-footerLen:]
+	if string(footer[0:8]) != "APETAGEX" {
+		return nil, false
+	}
+
+	tagSize := binary.LittleEndian.Uint32(footer[12:16])
+	itemCount := binary.LittleEndian.Uint32(footer[16:20])
+
+	if int64(tagSize) > int64(len(tail)) {
+		return nil, false
+	}
+	// tagSize covers the items plus this footer, but not the preceding
+	// (optional) header.
+	items := tail[len(tail)-int(tagSize) : len(tail)-footerLen]
+
+	m := newMetadata()
+	for i := uint32(0); i < itemCount && len(items) >= 8; i++ {
+		valueSize := binary.LittleEndian.Uint32(items[0:4])
+		items = items[8:] // skip size + flags
+
+		nameEnd := indexNull(items, 1)
+		if nameEnd < 0 {
+			break
+		}
+		name := string(items[:nameEnd])
+		items = items[nameEnd+1:]
+
+		if int64(valueSize) > int64(len(items)) {
+			break
+		}
+		value := string(items[:valueSize])
+		items = items[valueSize:]
+
+		switch name {
+		case "Title":
+			m.Title = value
+		case "Artist":
+			m.Artist = value
+		case "Album":
+			m.Album = value
+		case "Track":
+			m.TrackNumber = value
+		case "Year":
+			m.Year = value
+		case "Genre":
+			m.Genre = value
+		case "Comment":
+			m.Comment = value
+		default:
+			m.addFrame(name, value)
+		}
+	}
+
+	return m, true
+}
@@ -0,0 +1,201 @@
+package id3
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func id3v2TextFrame(id, text string) []byte {
+	payload := append([]byte{3}, []byte(text)...) // encoding 3 = UTF-8
+	frame := make([]byte, 0, 10+len(payload))
+	frame = append(frame, []byte(id)...)
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(payload)))
+	frame = append(frame, size[:]...)
+	frame = append(frame, 0, 0) // flags
+	frame = append(frame, payload...)
+	return frame
+}
+
+func buildID3v2(frames ...[]byte) []byte {
+	var body []byte
+	for _, f := range frames {
+		body = append(body, f...)
+	}
+
+	buf := make([]byte, 10)
+	copy(buf[0:3], "ID3")
+	buf[3] = 3 // version 2.3
+	buf[4] = 0 // revision
+	buf[5] = 0 // flags
+	synchsafeEncode(buf[6:10], uint32(len(body)))
+	return append(buf, body...)
+}
+
+func synchsafeEncode(dst []byte, v uint32) {
+	dst[0] = byte(v >> 21 & 0x7f)
+	dst[1] = byte(v >> 14 & 0x7f)
+	dst[2] = byte(v >> 7 & 0x7f)
+	dst[3] = byte(v & 0x7f)
+}
+
+func TestParseID3v2TextFrames(t *testing.T) {
+	buf := buildID3v2(
+		id3v2TextFrame("TIT2", "Test Title"),
+		id3v2TextFrame("TPE1", "Test Artist"),
+		id3v2TextFrame("TALB", "Test Album"),
+	)
+
+	m, size, ok := ParseID3v2(buf)
+	if !ok {
+		t.Fatal("expected ParseID3v2 to succeed")
+	}
+	if size != int64(len(buf)) {
+		t.Errorf("size = %d, want %d", size, len(buf))
+	}
+	if m.Title != "Test Title" {
+		t.Errorf("Title = %q, want %q", m.Title, "Test Title")
+	}
+	if m.Artist != "Test Artist" {
+		t.Errorf("Artist = %q, want %q", m.Artist, "Test Artist")
+	}
+	if m.Album != "Test Album" {
+		t.Errorf("Album = %q, want %q", m.Album, "Test Album")
+	}
+}
+
+func TestParseID3v2TXXX(t *testing.T) {
+	payload := []byte{3} // UTF-8
+	payload = append(payload, []byte("REPLAYGAIN_TRACK_GAIN")...)
+	payload = append(payload, 0)
+	payload = append(payload, []byte("-6.20 dB")...)
+
+	frame := make([]byte, 0, 10+len(payload))
+	frame = append(frame, []byte("TXXX")...)
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(payload)))
+	frame = append(frame, size[:]...)
+	frame = append(frame, 0, 0)
+	frame = append(frame, payload...)
+
+	buf := buildID3v2(frame)
+	m, _, ok := ParseID3v2(buf)
+	if !ok {
+		t.Fatal("expected ParseID3v2 to succeed")
+	}
+	got := m.Frames["TXXX:REPLAYGAIN_TRACK_GAIN"]
+	if len(got) != 1 || got[0] != "-6.20 dB" {
+		t.Errorf("TXXX:REPLAYGAIN_TRACK_GAIN = %v, want [%q]", got, "-6.20 dB")
+	}
+}
+
+func TestParseID3v2NoHeader(t *testing.T) {
+	if _, _, ok := ParseID3v2([]byte("not an id3 tag")); ok {
+		t.Error("expected ok = false for a buffer without an ID3 header")
+	}
+}
+
+func TestParseID3v1(t *testing.T) {
+	tag := make([]byte, 128)
+	copy(tag[0:3], "TAG")
+	copy(tag[3:33], "Track Title")
+	copy(tag[33:63], "Track Artist")
+	copy(tag[63:93], "Track Album")
+	copy(tag[93:97], "2024")
+	copy(tag[97:125], "A comment")
+	tag[125] = 0  // zero byte marks ID3v1.1
+	tag[126] = 5  // track number
+	tag[127] = 17 // genre: Rock
+
+	m, ok := ParseID3v1(tag)
+	if !ok {
+		t.Fatal("expected ParseID3v1 to succeed")
+	}
+	if m.Title != "Track Title" {
+		t.Errorf("Title = %q, want %q", m.Title, "Track Title")
+	}
+	if m.Artist != "Track Artist" {
+		t.Errorf("Artist = %q, want %q", m.Artist, "Track Artist")
+	}
+	if m.TrackNumber != "5" {
+		t.Errorf("TrackNumber = %q, want %q", m.TrackNumber, "5")
+	}
+	if m.Genre != "Rock" {
+		t.Errorf("Genre = %q, want %q", m.Genre, "Rock")
+	}
+}
+
+func TestParseID3v1WrongMagic(t *testing.T) {
+	tag := make([]byte, 128)
+	copy(tag[0:3], "XXX")
+	if _, ok := ParseID3v1(tag); ok {
+		t.Error("expected ok = false for a buffer without a TAG magic")
+	}
+}
+
+func TestParseAPEv2(t *testing.T) {
+	item := func(key, value string) []byte {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint32(b[0:4], uint32(len(value)))
+		// flags left as zero (UTF-8 text item)
+		b = append(b, []byte(key)...)
+		b = append(b, 0)
+		b = append(b, []byte(value)...)
+		return b
+	}
+
+	items := append(item("Title", "APE Title"), item("Artist", "APE Artist")...)
+
+	footer := make([]byte, 32)
+	copy(footer[0:8], "APETAGEX")
+	binary.LittleEndian.PutUint32(footer[8:12], 2000) // version
+	binary.LittleEndian.PutUint32(footer[12:16], uint32(len(items)+32))
+	binary.LittleEndian.PutUint32(footer[16:20], 2) // item count
+
+	tail := append(items, footer...)
+
+	m, ok := ParseAPEv2(tail)
+	if !ok {
+		t.Fatal("expected ParseAPEv2 to succeed")
+	}
+	if m.Title != "APE Title" {
+		t.Errorf("Title = %q, want %q", m.Title, "APE Title")
+	}
+	if m.Artist != "APE Artist" {
+		t.Errorf("Artist = %q, want %q", m.Artist, "APE Artist")
+	}
+}
+
+func TestParseAPEv2NoFooter(t *testing.T) {
+	if _, ok := ParseAPEv2(make([]byte, 32)); ok {
+		t.Error("expected ok = false for a buffer without an APETAGEX footer")
+	}
+}
+
+func TestMergePrefersEarlierTags(t *testing.T) {
+	id3v2 := &Metadata{Title: "From ID3v2", Frames: map[string][]string{}}
+	id3v1 := &Metadata{Title: "From ID3v1", Artist: "Only in ID3v1", Frames: map[string][]string{}}
+
+	merged := Merge(id3v2, id3v1)
+	if merged.Title != "From ID3v2" {
+		t.Errorf("Title = %q, want %q (earlier argument should win)", merged.Title, "From ID3v2")
+	}
+	if merged.Artist != "Only in ID3v1" {
+		t.Errorf("Artist = %q, want %q (fallback from later argument)", merged.Artist, "Only in ID3v1")
+	}
+}
+
+func TestMergeFramesPriority(t *testing.T) {
+	id3v2 := &Metadata{Frames: map[string][]string{
+		"TXXX:REPLAYGAIN_TRACK_GAIN": {"-3.00 dB"},
+	}}
+	ape := &Metadata{Frames: map[string][]string{
+		"TXXX:REPLAYGAIN_TRACK_GAIN": {"-6.00 dB"},
+	}}
+
+	merged := Merge(id3v2, ape)
+	vs := merged.Frames["TXXX:REPLAYGAIN_TRACK_GAIN"]
+	if len(vs) != 2 || vs[0] != "-3.00 dB" {
+		t.Errorf("Frames[...] = %v, want [-3.00 dB -6.00 dB] (earlier argument first)", vs)
+	}
+}
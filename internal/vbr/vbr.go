@@ -0,0 +1,178 @@
+// Package vbr parses the Xing/Info and VBRI variable-bitrate headers that
+// encoders embed in the first MP3 frame of a stream. These headers let a
+// decoder learn the total frame/byte count and a seek table without
+// scanning the rest of the file.
+package vbr
+
+import "encoding/binary"
+
+// Kind identifies which VBR header tag was found.
+type Kind int
+
+const (
+	// None means no recognized VBR header was present.
+	None Kind = iota
+	// Xing covers both the "Xing" and "Info" tags, which share a layout.
+	Xing
+	// VBRI is Fraunhofer's encoder tag, used mostly by early LAME builds.
+	VBRI
+)
+
+// Xing/Info flag bits, see the flags word right after the tag name.
+const (
+	flagFrames       = 1 << 0
+	flagBytes        = 1 << 1
+	flagTOC          = 1 << 2
+	flagQualityField = 1 << 3
+)
+
+// xingOffsets are the byte offsets from the end of the 4-byte frame header
+// to the start of the Xing/Info tag, depending on MPEG version and channel
+// mode. All three are tried since the caller does not always know the
+// frame layout up front.
+var xingOffsets = [...]int{32, 17, 9}
+
+// vbriOffset is the byte offset of the VBRI tag from the end of the
+// 4-byte frame header. The spec places it at absolute frame offset 36,
+// which is 32 bytes past the header.
+const vbriOffset = 32
+
+// Header is the information extracted from a Xing/Info or VBRI tag.
+type Header struct {
+	Kind    Kind
+	Frames  uint32
+	Bytes   uint32
+	Quality uint32
+	// TOC maps percentage (index 0-99) to a fraction of Bytes, in 256ths
+	// (values 0-255). Only valid when HasTOC is true.
+	TOC    [100]byte
+	HasTOC bool
+}
+
+// Parse looks for a Xing/Info or VBRI tag inside body, which must be the
+// frame's bytes immediately following its 4-byte header. It returns nil if
+// no VBR tag is present, in which case the frame should be treated as CBR.
+func Parse(body []byte) *Header {
+	if h := parseXing(body); h != nil {
+		return h
+	}
+	return parseVBRI(body)
+}
+
+func parseXing(body []byte) *Header {
+	for _, off := range xingOffsets {
+		if off+8 > len(body) {
+			continue
+		}
+		tag := string(body[off : off+4])
+		if tag != "Xing" && tag != "Info" {
+			continue
+		}
+
+		flags := binary.BigEndian.Uint32(body[off+4 : off+8])
+		p := off + 8
+		h := &Header{Kind: Xing}
+
+		if flags&flagFrames != 0 {
+			if p+4 > len(body) {
+				return h
+			}
+			h.Frames = binary.BigEndian.Uint32(body[p : p+4])
+			p += 4
+		}
+		if flags&flagBytes != 0 {
+			if p+4 > len(body) {
+				return h
+			}
+			h.Bytes = binary.BigEndian.Uint32(body[p : p+4])
+			p += 4
+		}
+		if flags&flagTOC != 0 {
+			if p+100 > len(body) {
+				return h
+			}
+			copy(h.TOC[:], body[p:p+100])
+			h.HasTOC = true
+			p += 100
+		}
+		if flags&flagQualityField != 0 && p+4 <= len(body) {
+			h.Quality = binary.BigEndian.Uint32(body[p : p+4])
+		}
+		return h
+	}
+	return nil
+}
+
+func parseVBRI(body []byte) *Header {
+	if vbriOffset+4 > len(body) || string(body[vbriOffset:vbriOffset+4]) != "VBRI" {
+		return nil
+	}
+	// Layout (big endian): "VBRI", version(2), delay(2), quality(2),
+	// bytes(4), frames(4), entries(2), scale(2), bytes-per-entry(2),
+	// frames-per-entry(2), then entries*bytes-per-entry TOC bytes.
+	const hdr = vbriOffset + 4
+	if hdr+22 > len(body) {
+		return nil
+	}
+	quality := binary.BigEndian.Uint16(body[hdr+4 : hdr+6])
+	bytes := binary.BigEndian.Uint32(body[hdr+6 : hdr+10])
+	frames := binary.BigEndian.Uint32(body[hdr+10 : hdr+14])
+	entries := binary.BigEndian.Uint16(body[hdr+14 : hdr+16])
+	scale := binary.BigEndian.Uint16(body[hdr+16 : hdr+18])
+	bytesPerEntry := binary.BigEndian.Uint16(body[hdr+18 : hdr+20])
+	framesPerEntryField := binary.BigEndian.Uint16(body[hdr+20 : hdr+22])
+
+	h := &Header{
+		Kind:    VBRI,
+		Frames:  frames,
+		Bytes:   bytes,
+		Quality: uint32(quality),
+	}
+
+	// The VBRI TOC gives absolute per-entry deltas rather than the
+	// Xing-style 0-255 fractions, so it is normalized down to the same
+	// 100-bucket percentage TOC the decoder consults for seeking.
+	tocStart := hdr + 22
+	tocLen := int(entries) * int(bytesPerEntry)
+	if tocLen == 0 || tocStart+tocLen > len(body) {
+		return h
+	}
+
+	cumBytes := uint64(0)
+	cumFrames := uint64(0)
+	framesPerEntry := uint64(framesPerEntryField)
+	if framesPerEntry == 0 {
+		framesPerEntry = 1
+	}
+	byteScale := uint64(scale)
+	if byteScale == 0 {
+		byteScale = 1
+	}
+	bucket := 0
+	for i := 0; i < int(entries) && bucket < 100; i++ {
+		off := tocStart + i*int(bytesPerEntry)
+		var delta uint64
+		switch bytesPerEntry {
+		case 2:
+			delta = uint64(binary.BigEndian.Uint16(body[off : off+2]))
+		case 4:
+			delta = uint64(binary.BigEndian.Uint32(body[off : off+4]))
+		default:
+			return h
+		}
+		cumBytes += delta * byteScale
+		cumFrames += framesPerEntry
+
+		for bucket < 100 && uint64(bucket+1)*uint64(frames)/100 <= cumFrames {
+			if bytes > 0 {
+				h.TOC[bucket] = byte(cumBytes * 256 / uint64(bytes))
+			}
+			bucket++
+		}
+	}
+	for ; bucket < 100; bucket++ {
+		h.TOC[bucket] = 255
+	}
+	h.HasTOC = true
+	return h
+}
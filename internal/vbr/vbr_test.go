@@ -0,0 +1,110 @@
+package vbr
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// buildXingBody builds a synthetic frame body (header already stripped)
+// carrying a Xing tag with frames/bytes/TOC at the given body-relative
+// offset.
+func buildXingBody(offset int, frames, bytes uint32) []byte {
+	body := make([]byte, offset+8+4+4+100)
+	copy(body[offset:], "Xing")
+	binary.BigEndian.PutUint32(body[offset+4:], flagFrames|flagBytes|flagTOC)
+	binary.BigEndian.PutUint32(body[offset+8:], frames)
+	binary.BigEndian.PutUint32(body[offset+12:], bytes)
+	for i := 0; i < 100; i++ {
+		body[offset+16+i] = byte(i * 256 / 100)
+	}
+	return body
+}
+
+func TestParseXingAtEachOffset(t *testing.T) {
+	for _, offset := range xingOffsets {
+		body := buildXingBody(offset, 1000, 500000)
+		h := Parse(body)
+		if h == nil {
+			t.Fatalf("offset %d: expected a Xing header, got nil", offset)
+		}
+		if h.Kind != Xing {
+			t.Errorf("offset %d: Kind = %v, want Xing", offset, h.Kind)
+		}
+		if h.Frames != 1000 {
+			t.Errorf("offset %d: Frames = %d, want 1000", offset, h.Frames)
+		}
+		if h.Bytes != 500000 {
+			t.Errorf("offset %d: Bytes = %d, want 500000", offset, h.Bytes)
+		}
+		if !h.HasTOC {
+			t.Errorf("offset %d: HasTOC = false, want true", offset)
+		}
+	}
+}
+
+func TestParseXingInfoTagName(t *testing.T) {
+	body := buildXingBody(xingOffsets[0], 10, 20)
+	copy(body[xingOffsets[0]:], "Info")
+	h := Parse(body)
+	if h == nil || h.Kind != Xing {
+		t.Fatalf("expected an Info tag to parse as Kind Xing, got %+v", h)
+	}
+}
+
+// buildVBRIBody builds a synthetic frame body carrying a VBRI tag. The tag
+// itself always starts at absolute frame offset 36, i.e. vbriOffset bytes
+// into the header-stripped body.
+func buildVBRIBody(frames, bytes uint32) []byte {
+	const entries = 10
+	const bytesPerEntry = 2
+	body := make([]byte, vbriOffset+4+22+entries*bytesPerEntry)
+
+	tag := body[vbriOffset:]
+	copy(tag, "VBRI")
+	binary.BigEndian.PutUint16(tag[4:], 1)       // version
+	binary.BigEndian.PutUint16(tag[6:], 0)       // delay
+	binary.BigEndian.PutUint16(tag[8:], 78)      // quality
+	binary.BigEndian.PutUint32(tag[10:], bytes)  // total bytes
+	binary.BigEndian.PutUint32(tag[14:], frames) // total frames
+	binary.BigEndian.PutUint16(tag[18:], entries)
+	binary.BigEndian.PutUint16(tag[20:], 1) // scale: TOC entries are in bytes
+	binary.BigEndian.PutUint16(tag[22:], bytesPerEntry)
+	binary.BigEndian.PutUint16(tag[24:], 1) // frames per entry
+
+	toc := tag[26:]
+	perEntry := uint16(bytes / entries)
+	for i := 0; i < entries; i++ {
+		binary.BigEndian.PutUint16(toc[i*bytesPerEntry:], perEntry)
+	}
+	return body
+}
+
+func TestParseVBRI(t *testing.T) {
+	body := buildVBRIBody(2000, 800000)
+	h := Parse(body)
+	if h == nil {
+		t.Fatal("expected a VBRI header, got nil")
+	}
+	if h.Kind != VBRI {
+		t.Errorf("Kind = %v, want VBRI", h.Kind)
+	}
+	if h.Frames != 2000 {
+		t.Errorf("Frames = %d, want 2000", h.Frames)
+	}
+	if h.Bytes != 800000 {
+		t.Errorf("Bytes = %d, want 800000", h.Bytes)
+	}
+	if !h.HasTOC {
+		t.Fatal("HasTOC = false, want true")
+	}
+	if h.TOC[99] < h.TOC[0] {
+		t.Errorf("TOC should be non-decreasing, got TOC[0]=%d TOC[99]=%d", h.TOC[0], h.TOC[99])
+	}
+}
+
+func TestParseNoTag(t *testing.T) {
+	body := make([]byte, 200)
+	if h := Parse(body); h != nil {
+		t.Errorf("expected nil for a frame with no VBR tag, got %+v", h)
+	}
+}
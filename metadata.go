@@ -0,0 +1,162 @@
+// !!! NEW TO DERIVATIVE WORK !!!
+//
+// This file exposes the ID3v1, ID3v2 and APEv2 tags embedded in an MP3
+// file, which NewDecoder previously discarded via source.skipTags.
+
+package mp3
+
+import (
+	"io"
+
+	"github.com/sukus21/go-mp3/internal/id3"
+)
+
+// Metadata is the track information parsed from a file's ID3v1, ID3v2
+// and/or APEv2 tags. See (*Decoder).Metadata.
+type Metadata = id3.Metadata
+
+// Metadata returns the tags found in the stream, or nil if none were found
+// or the source is not an io.Seeker.
+func (d *Decoder) Metadata() *Metadata {
+	return d.metadata
+}
+
+// parseMetadata reads and parses any ID3v2 header, trailing ID3v1 tag and
+// trailing APEv2 tag present in the source. It requires the source to be
+// an io.Seeker and leaves the read position unchanged.
+func (d *Decoder) parseMetadata() error {
+	if _, ok := d.source.reader.(io.Seeker); !ok {
+		return nil
+	}
+
+	pos, err := d.source.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	var tags []*id3.Metadata
+
+	v2, err := d.readID3v2()
+	if err != nil {
+		return err
+	}
+	if v2 != nil {
+		tags = append(tags, v2)
+	}
+
+	end, err := d.source.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	apeSearchEnd := end
+	v1, err := d.readID3v1(end)
+	if err != nil {
+		return err
+	}
+	if v1 != nil {
+		tags = append(tags, v1)
+		apeSearchEnd -= 128
+	}
+
+	ape, err := d.readAPEv2(apeSearchEnd)
+	if err != nil {
+		return err
+	}
+	if ape != nil {
+		tags = append(tags, ape)
+	}
+
+	if len(tags) > 0 {
+		d.metadata = id3.Merge(tags...)
+	}
+
+	_, err = d.source.Seek(pos, io.SeekStart)
+	return err
+}
+
+func (d *Decoder) readID3v2() (*id3.Metadata, error) {
+	if _, err := d.source.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 10)
+	if _, err := d.source.ReadFull(header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if string(header[0:3]) != "ID3" {
+		return nil, nil
+	}
+
+	bodySize := int64(header[6]&0x7f)<<21 | int64(header[7]&0x7f)<<14 | int64(header[8]&0x7f)<<7 | int64(header[9]&0x7f)
+	buf := append(header, make([]byte, bodySize)...)
+	if _, err := d.source.ReadFull(buf[10:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	tag, _, ok := id3.ParseID3v2(buf)
+	if !ok {
+		return nil, nil
+	}
+	return tag, nil
+}
+
+func (d *Decoder) readID3v1(end int64) (*id3.Metadata, error) {
+	if end < 128 {
+		return nil, nil
+	}
+	if _, err := d.source.Seek(end-128, io.SeekStart); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 128)
+	if _, err := d.source.ReadFull(buf); err != nil {
+		return nil, err
+	}
+	tag, ok := id3.ParseID3v1(buf)
+	if !ok {
+		return nil, nil
+	}
+	return tag, nil
+}
+
+func (d *Decoder) readAPEv2(end int64) (*id3.Metadata, error) {
+	const footerLen = 32
+	if end < footerLen {
+		return nil, nil
+	}
+	if _, err := d.source.Seek(end-footerLen, io.SeekStart); err != nil {
+		return nil, err
+	}
+	footer := make([]byte, footerLen)
+	if _, err := d.source.ReadFull(footer); err != nil {
+		return nil, err
+	}
+	if string(footer[0:8]) != "APETAGEX" {
+		return nil, nil
+	}
+
+	tagSize := int64(footer[12]) | int64(footer[13])<<8 | int64(footer[14])<<16 | int64(footer[15])<<24
+	if tagSize <= 0 || tagSize > end {
+		return nil, nil
+	}
+
+	if _, err := d.source.Seek(end-tagSize, io.SeekStart); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, tagSize)
+	if _, err := d.source.ReadFull(buf); err != nil {
+		return nil, err
+	}
+
+	tag, ok := id3.ParseAPEv2(buf)
+	if !ok {
+		return nil, nil
+	}
+	return tag, nil
+}
@@ -0,0 +1,145 @@
+package mp3
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseGainDB(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"-6.20 dB", -6.2, true},
+		{"+3.50 dB", 3.5, true},
+		{"0.00 dB", 0, true},
+		{"  -1.23dB ", -1.23, true},
+		{"not a number", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseGainDB(tt.in)
+		if ok != tt.ok {
+			t.Errorf("parseGainDB(%q) ok = %v, want %v", tt.in, ok, tt.ok)
+			continue
+		}
+		if ok && got != tt.want {
+			t.Errorf("parseGainDB(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReplayGainSelected(t *testing.T) {
+	rg := replayGain{
+		trackGainDB: -3, trackPeak: 0.9, hasTrackGain: true,
+		albumGainDB: -5, albumPeak: 0.8, hasAlbumGain: true,
+	}
+
+	rg.mode = RGTrack
+	if gain, peak, ok := rg.selected(); !ok || gain != -3 || peak != 0.9 {
+		t.Errorf("RGTrack: got (%v, %v, %v), want (-3, 0.9, true)", gain, peak, ok)
+	}
+
+	rg.mode = RGAlbum
+	if gain, peak, ok := rg.selected(); !ok || gain != -5 || peak != 0.8 {
+		t.Errorf("RGAlbum: got (%v, %v, %v), want (-5, 0.8, true)", gain, peak, ok)
+	}
+
+	rg.mode = RGAuto
+	if gain, _, ok := rg.selected(); !ok || gain != -5 {
+		t.Errorf("RGAuto with album gain present: got (%v, _, %v), want (-5, true)", gain, ok)
+	}
+
+	rg.hasAlbumGain = false
+	rg.mode = RGAuto
+	if gain, _, ok := rg.selected(); !ok || gain != -3 {
+		t.Errorf("RGAuto falling back to track gain: got (%v, _, %v), want (-3, true)", gain, ok)
+	}
+
+	rg.mode = RGOff
+	if _, _, ok := rg.selected(); ok {
+		t.Error("RGOff: expected ok = false")
+	}
+}
+
+func TestReplayGainMultiplierIsNotPreClipped(t *testing.T) {
+	d := &Decoder{}
+	d.rg.mode = RGTrack
+	d.rg.hasTrackGain = true
+	d.rg.trackGainDB = 20 // a 10x linear gain
+	d.rg.trackPeak = 0.5  // a peak is known, but must not cap the multiplier itself
+
+	mult := d.replayGainMultiplier()
+	const want = float32(10.0)
+	const epsilon = 1e-3
+	if diff := mult - want; diff > epsilon || diff < -epsilon {
+		t.Errorf("replayGainMultiplier() = %v, want %v (full gain, uncapped)", mult, want)
+	}
+}
+
+func TestReplayGainMultiplierPositiveGainNoPeak(t *testing.T) {
+	d := &Decoder{}
+	d.rg.mode = RGTrack
+	d.rg.hasTrackGain = true
+	d.rg.trackGainDB = 6 // a real boost, no peak tag present
+
+	mult := d.replayGainMultiplier()
+	const want = float32(1.9953) // 10^(6/20)
+	const epsilon = 1e-3
+	if diff := mult - want; diff > epsilon || diff < -epsilon {
+		t.Errorf("replayGainMultiplier() = %v, want %v; a boost must not be silently discarded when no peak is tagged", mult, want)
+	}
+	if ceiling := d.replayGainCeiling(); ceiling != 1 {
+		t.Errorf("replayGainCeiling() = %v, want 1 (clip to full scale absent a peak)", ceiling)
+	}
+}
+
+func TestReplayGainMultiplierOffIsUnity(t *testing.T) {
+	d := &Decoder{}
+	if mult := d.replayGainMultiplier(); mult != 1 {
+		t.Errorf("replayGainMultiplier() with RGOff = %v, want 1", mult)
+	}
+}
+
+func TestReplayGainCeilingUsesTaggedPeak(t *testing.T) {
+	d := &Decoder{}
+	d.rg.mode = RGTrack
+	d.rg.hasTrackGain = true
+	d.rg.trackGainDB = 20
+	d.rg.trackPeak = 0.5
+
+	if ceiling := d.replayGainCeiling(); ceiling != 0.5 {
+		t.Errorf("replayGainCeiling() = %v, want 0.5", ceiling)
+	}
+}
+
+func TestClipS16LE(t *testing.T) {
+	pcm := make([]byte, 4)
+	s0, s1 := int16(30000), int16(-30000)
+	binary.LittleEndian.PutUint16(pcm[0:2], uint16(s0))
+	binary.LittleEndian.PutUint16(pcm[2:4], uint16(s1))
+
+	clipS16LE(pcm, 0.5)
+
+	got0 := int16(binary.LittleEndian.Uint16(pcm[0:2]))
+	got1 := int16(binary.LittleEndian.Uint16(pcm[2:4]))
+	if want := int16(16384); got0 != want {
+		t.Errorf("clipS16LE: sample 0 = %v, want %v", got0, want)
+	}
+	if want := int16(-16384); got1 != want {
+		t.Errorf("clipS16LE: sample 1 = %v, want %v", got1, want)
+	}
+}
+
+func TestClipS16LENoOpAtFullScale(t *testing.T) {
+	pcm := make([]byte, 2)
+	s := int16(32000)
+	binary.LittleEndian.PutUint16(pcm, uint16(s))
+
+	clipS16LE(pcm, 1)
+
+	if got := int16(binary.LittleEndian.Uint16(pcm)); got != 32000 {
+		t.Errorf("clipS16LE with ceiling 1 modified the sample: got %v, want 32000", got)
+	}
+}